@@ -0,0 +1,43 @@
+// Package backend defines the storage/transport abstraction that sandworm's
+// sync logic runs against, so the same reconciliation code (see package
+// syncer) can target Claude Projects, a local directory, or any other
+// destination that can list, upload, and delete named documents.
+package backend
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotFound is returned by DeleteDocument when the document is already
+// gone. Callers generally treat this as success rather than a failure.
+var ErrNotFound = errors.New("document not found")
+
+// Document identifies a single document stored at a backend.
+type Document struct {
+	ID       string
+	FileName string
+}
+
+// Backend is the set of operations a sync destination must support: upload
+// a named file, list the files currently stored, and delete a file by ID.
+// Implementations live in subpackages, e.g. backend/fs, and are selected by
+// the caller (typically via a "backend" config value).
+type Backend interface {
+	// Setup initializes any configuration the backend needs, prompting for
+	// required values if they're not already set.
+	Setup(ctx context.Context, force bool) (bool, error)
+
+	// ListDocuments returns every document currently stored at the
+	// destination.
+	ListDocuments(ctx context.Context) ([]Document, error)
+
+	// UploadDocument stores content under fileName and returns the created
+	// document.
+	UploadDocument(ctx context.Context, fileName, content string) (Document, error)
+
+	// DeleteDocument removes the document with the given ID. Implementations
+	// should return ErrNotFound rather than a generic error when the
+	// document is already gone.
+	DeleteDocument(ctx context.Context, id string) error
+}
@@ -0,0 +1,103 @@
+// Package fs implements a backend.Backend that stores documents as plain
+// files in a local directory. It's useful for dry runs and tests, where
+// uploading to Claude Projects would be unwanted or unavailable.
+package fs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/holonoms/sandworm/internal/backend"
+)
+
+// Backend stores documents as files under Dir. Since the local filesystem
+// has no separate document-ID concept, a document's ID is its file name.
+type Backend struct {
+	Dir string
+}
+
+// Backend implements backend.Backend.
+var _ backend.Backend = (*Backend)(nil)
+
+// New creates a filesystem backend rooted at dir.
+func New(dir string) *Backend {
+	return &Backend{Dir: dir}
+}
+
+// Setup ensures the backing directory exists. force is accepted for
+// interface compatibility but has no effect, since there's no other
+// configuration to (re)collect.
+func (b *Backend) Setup(_ context.Context, _ bool) (bool, error) {
+	if err := os.MkdirAll(b.Dir, 0o755); err != nil {
+		return false, fmt.Errorf("failed to create backend directory: %w", err)
+	}
+	return true, nil
+}
+
+// ListDocuments returns every file currently stored under Dir.
+func (b *Backend) ListDocuments(_ context.Context) ([]backend.Document, error) {
+	entries, err := os.ReadDir(b.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list backend directory: %w", err)
+	}
+
+	var docs []backend.Document
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		docs = append(docs, backend.Document{ID: entry.Name(), FileName: entry.Name()})
+	}
+	return docs, nil
+}
+
+// UploadDocument writes content to fileName under Dir, creating Dir if
+// necessary.
+func (b *Backend) UploadDocument(_ context.Context, fileName, content string) (backend.Document, error) {
+	if !isFlatFileName(fileName) {
+		return backend.Document{}, fmt.Errorf("invalid file name %q: must be a flat name, not a path", fileName)
+	}
+
+	if err := os.MkdirAll(b.Dir, 0o755); err != nil {
+		return backend.Document{}, fmt.Errorf("failed to create backend directory: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(b.Dir, fileName), []byte(content), 0o644); err != nil {
+		return backend.Document{}, fmt.Errorf("failed to write %q: %w", fileName, err)
+	}
+
+	return backend.Document{ID: fileName, FileName: fileName}, nil
+}
+
+// DeleteDocument removes the file named id from Dir.
+func (b *Backend) DeleteDocument(_ context.Context, id string) error {
+	if !isFlatFileName(id) {
+		return fmt.Errorf("invalid file name %q: must be a flat name, not a path", id)
+	}
+
+	if err := os.Remove(filepath.Join(b.Dir, id)); err != nil {
+		if os.IsNotExist(err) {
+			return backend.ErrNotFound
+		}
+		return fmt.Errorf("failed to remove %q: %w", id, err)
+	}
+	return nil
+}
+
+// isFlatFileName reports whether name is safe to join directly onto Dir: a
+// single path element with no separators or ".."/"." components, so it can
+// neither write outside Dir nor require creating an intermediate directory.
+// Document IDs here are file names (see Backend's doc comment), which the
+// Claude backend treats as flat remote identifiers -- this backend enforces
+// the same.
+func isFlatFileName(name string) bool {
+	if name == "" || name == "." || name == ".." {
+		return false
+	}
+	return filepath.Base(name) == name
+}
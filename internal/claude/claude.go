@@ -2,42 +2,64 @@
 package claude
 
 import (
-	"bytes"
 	"compress/gzip"
 	"context"
-	"crypto/sha256"
 	"crypto/tls"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
-	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/holonoms/sandworm/internal/backend"
 	"github.com/holonoms/sandworm/internal/config"
 )
 
 const (
-	baseURL = "https://api.claude.ai"
-
 	// Configuration keys
 	sessionKey     = "claude.session_key" // Global, used across sandworm projects
 	organizationID = "claude.organization_id"
 	projectID      = "claude.project_id"
-	documentID     = "claude.document_id"
-	contentHashKey = "claude.content_hash" // Stores hash of last uploaded content
 )
 
+// baseURL is a var rather than a const so tests can point it at an
+// httptest.Server.
+var baseURL = "https://api.claude.ai"
+
 var sessionKeyRegex = regexp.MustCompile(`^sessionKey=([^;]+)`)
 
+// Client implements backend.Backend.
+var _ backend.Backend = (*Client)(nil)
+
+// defaultRequestTimeout is the per-call deadline applied when the caller
+// doesn't already supply one via context.
+const defaultRequestTimeout = 30 * time.Second
+
 // Client manages interactions with the Claude API
 type Client struct {
 	config     *config.Config
 	httpClient *http.Client
+
+	// RequestTimeout bounds how long a single call (including its retries)
+	// may take. It's only applied when the caller's context doesn't already
+	// carry a deadline. Defaults to defaultRequestTimeout.
+	RequestTimeout time.Duration
+
+	// RetryPolicy controls how makeRequest retries transient failures. Each
+	// field defaults independently (see defaultRetryPolicy) when left nil, so
+	// e.g. setting MaxRetries to point at 0 disables retries without having
+	// to also fill in every other field.
+	RetryPolicy RetryPolicy
+
+	// UploadProgressFn, if non-nil, is called as document content is
+	// streamed to the API during UploadDocument, so callers can render an
+	// upload bar analogous to PurgeProjectFiles' progress callback.
+	UploadProgressFn func(bytesSent, bytesTotal int64)
 }
 
 // New creates a new Claude API client using the provided configuration
@@ -56,15 +78,84 @@ func New(conf *config.Config) *Client {
 				TLSHandshakeTimeout: 5 * time.Second,
 			},
 		},
+		RequestTimeout: defaultRequestTimeout,
 	}
 }
 
-// MARK: Interface
+// RetryPolicy controls how makeRequest retries a failed request: how many
+// times, with what backoff, and which status codes are worth retrying at
+// all. Every field is a pointer (RetryableStatus a func) so a caller can override a single field
+// (e.g. MaxRetries) without having to also specify the others -- a nil field
+// takes its value from defaultRetryPolicy() rather than being treated as an
+// explicit zero. Set MaxRetries to point at 0 to disable retries entirely.
+type RetryPolicy struct {
+	MaxRetries      *int
+	InitialBackoff  *time.Duration
+	MaxBackoff      *time.Duration
+	Jitter          *float64
+	RetryableStatus func(status int) bool
+}
+
+// resolved fills in defaultRetryPolicy() for any field left nil, returning a
+// policy with every field set. Resolution happens per field rather than for
+// the struct as a whole, so e.g. RetryPolicy{MaxRetries: ptr(0)} disables
+// retries while still getting the default RetryableStatus.
+func (p RetryPolicy) resolved() RetryPolicy {
+	d := defaultRetryPolicy()
+	if p.MaxRetries == nil {
+		p.MaxRetries = d.MaxRetries
+	}
+	if p.InitialBackoff == nil {
+		p.InitialBackoff = d.InitialBackoff
+	}
+	if p.MaxBackoff == nil {
+		p.MaxBackoff = d.MaxBackoff
+	}
+	if p.Jitter == nil {
+		p.Jitter = d.Jitter
+	}
+	if p.RetryableStatus == nil {
+		p.RetryableStatus = d.RetryableStatus
+	}
+	return p
+}
+
+// defaultRetryPolicy preserves the client's original retry behavior: up to
+// 2 retries (3 attempts in all) of 5xx and 429 responses, with exponential
+// backoff.
+func defaultRetryPolicy() RetryPolicy {
+	maxRetries, initialBackoff, maxBackoff, jitter := 2, 1*time.Second, 30*time.Second, 0.1
+	return RetryPolicy{
+		MaxRetries:     &maxRetries,
+		InitialBackoff: &initialBackoff,
+		MaxBackoff:     &maxBackoff,
+		Jitter:         &jitter,
+		RetryableStatus: func(status int) bool {
+			return status >= 500 || status == http.StatusTooManyRequests
+		},
+	}
+}
+
+// RateLimitError is returned when makeRequest exhausts its retries against a
+// rate-limited (429) or overloaded (503) response, so callers can
+// distinguish throttling from other kinds of failures.
+type RateLimitError struct {
+	StatusCode int
+	RetryAfter time.Duration
+	Body       string
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited by Claude API (status %d, retry after %s): %s",
+		e.StatusCode, e.RetryAfter, e.Body)
+}
+
+// MARK: backend.Backend
 
 // Setup initializes the client configuration, prompting for required values
 // if they're not already set. It validates organization access and project
-// selection.
-func (c *Client) Setup(force bool) (bool, error) {
+// selection. It implements backend.Backend.
+func (c *Client) Setup(ctx context.Context, force bool) (bool, error) {
 	// Handle session key setup
 	if force || !c.config.Has(sessionKey) {
 		fmt.Println("\nPlease go to https://claude.ai in your browser and copy your session key from the Cookie header.")
@@ -82,7 +173,7 @@ func (c *Client) Setup(force bool) (bool, error) {
 
 	// Handle organization selection
 	if force || !c.config.Has(organizationID) {
-		orgs, err := c.listOrganizations()
+		orgs, err := c.listOrganizations(ctx)
 		if err != nil {
 			return false, err
 		}
@@ -101,7 +192,7 @@ func (c *Client) Setup(force bool) (bool, error) {
 
 	// Handle project selection
 	if force || !c.config.Has(projectID) {
-		projects, err := c.listProjects()
+		projects, err := c.listProjects(ctx)
 		if err != nil {
 			return false, err
 		}
@@ -128,117 +219,57 @@ func (c *Client) Setup(force bool) (bool, error) {
 	return true, nil
 }
 
-// Push uploads a file to the selected Claude project. If a file with the same
-// name exists, it's replaced, but only if the content has changed.
-func (c *Client) Push(filePath, fileName string) error {
+// ListDocuments returns every document in the selected Claude project. It
+// implements backend.Backend.
+func (c *Client) ListDocuments(ctx context.Context) ([]backend.Document, error) {
 	if err := c.validateConfig(); err != nil {
-		return err
+		return nil, err
 	}
 
-	// Read new file content
-	content, err := os.ReadFile(filePath)
+	docs, err := c.listDocuments(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+		return nil, err
 	}
 
-	// Calculate content hash
-	contentHash := calculateContentHash(content)
-
-	// Check if content is unchanged from last push
-	if c.config.Has(contentHashKey) && c.config.Get(contentHashKey) == contentHash {
-		// If we already have a document ID and the content is unchanged,
-		// no need to re-upload
-		if c.config.Has(documentID) {
-			fmt.Println("Content unchanged, skipping upload.")
-			return nil
-		}
-	}
-
-	// If no document ID is set, try to find existing document
-	if !c.config.Has(documentID) {
-		docs, err := c.listDocuments()
-		if err != nil {
-			return err
-		}
-		for _, doc := range docs {
-			if doc.FileName == fileName {
-				if err := c.config.Set(documentID, doc.ID); err != nil {
-					return err
-				}
-				break
-			}
-		}
+	out := make([]backend.Document, len(docs))
+	for i, doc := range docs {
+		out[i] = backend.Document{ID: doc.ID, FileName: doc.FileName}
 	}
+	return out, nil
+}
 
-	// Delete existing document if we have one
-	if c.config.Has(documentID) {
-		if err := c.deleteDocument(c.config.Get(documentID)); err != nil {
-			// Only return error if it's not a 404
-			if !strings.Contains(err.Error(), "404") {
-				return err
-			}
-		}
-		if err := c.config.Delete(documentID); err != nil {
-			return err
-		}
+// UploadDocument uploads content to the selected Claude project under
+// fileName. It implements backend.Backend.
+func (c *Client) UploadDocument(ctx context.Context, fileName, content string) (backend.Document, error) {
+	if err := c.validateConfig(); err != nil {
+		return backend.Document{}, err
 	}
 
-	// Upload new document
-	doc, err := c.uploadDocument(fileName, string(content))
+	doc, err := c.uploadDocument(ctx, fileName, content)
 	if err != nil {
-		return err
-	}
-
-	// Store document ID and content hash
-	if err := c.config.Set(documentID, doc.ID); err != nil {
-		return err
+		return backend.Document{}, err
 	}
-	return c.config.Set(contentHashKey, contentHash)
+	return backend.Document{ID: doc.ID, FileName: doc.FileName}, nil
 }
 
-// PurgeProjectFiles removes all files from the current project.
-func (c *Client) PurgeProjectFiles(progressFn func(fileName string, current, total int)) (int, error) {
+// DeleteDocument removes a document from the selected Claude project. It
+// implements backend.Backend.
+func (c *Client) DeleteDocument(ctx context.Context, id string) error {
 	if err := c.validateConfig(); err != nil {
-		return 0, err
-	}
-
-	docs, err := c.listDocuments()
-	if err != nil {
-		return 0, err
+		return err
 	}
 
-	for i, doc := range docs {
-		if progressFn != nil {
-			progressFn(doc.FileName, i+1, len(docs))
+	if err := c.deleteDocument(ctx, id); err != nil {
+		if strings.Contains(err.Error(), "404") {
+			return backend.ErrNotFound
 		}
-
-		if err := c.deleteDocument(doc.ID); err != nil {
-			// Only return error if it's not a 404
-			if !strings.Contains(err.Error(), "404") {
-				return i, err
-			}
-		}
-	}
-
-	// Clear stored document ID and content hash
-	if err := c.config.Delete(documentID); err != nil {
-		return len(docs), err
-	}
-	if err := c.config.Delete(contentHashKey); err != nil {
-		return len(docs), err
+		return err
 	}
-
-	return len(docs), nil
+	return nil
 }
 
 // MARK: Internal helper functions
 
-// calculateContentHash computes a SHA-256 hash of the content
-func calculateContentHash(content []byte) string {
-	hash := sha256.Sum256(content)
-	return hex.EncodeToString(hash[:])
-}
-
 // validateConfig ensures all required configuration values are present
 func (c *Client) validateConfig() error {
 	required := []string{sessionKey, organizationID, projectID}
@@ -254,63 +285,108 @@ func (c *Client) validateConfig() error {
 	return nil
 }
 
-// makeRequest performs an HTTP request to the Claude API with timeout and retry
-func (c *Client) makeRequest(method, path string, body interface{}) ([]byte, error) {
-	var bodyReader io.Reader
-	if body != nil {
-		data, err := json.Marshal(body)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal request body: %w", err)
-		}
-		bodyReader = bytes.NewReader(data)
+// makeRequest performs an HTTP request to the Claude API with timeout and
+// retry. newBody, if non-nil, is called to get a fresh request body for each
+// attempt -- it must be safe to call more than once, since a body already
+// consumed by a failed attempt can't be replayed. Pass nil for methods that
+// don't send a body. contentLength, if >= 0, is set as the request's
+// Content-Length so the server (and any progress reporting on the body)
+// knows the total size up front.
+func (c *Client) makeRequest(ctx context.Context, method, path string, newBody func() io.Reader, contentLength int64) ([]byte, error) {
+	// Apply the client's default timeout unless the caller's context already
+	// carries a deadline -- an explicit deadline replaces the default rather
+	// than stacking on top of it.
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.RequestTimeout)
+		defer cancel()
 	}
 
-	// Set up the request
-	req, err := http.NewRequest(method, baseURL+"/api"+path, bodyReader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
+	// newRequest builds a fresh *http.Request, including a fresh body, so
+	// that retrying doesn't reuse a body already drained by a prior attempt.
+	newRequest := func() (*http.Request, error) {
+		var bodyReader io.Reader
+		if newBody != nil {
+			bodyReader = newBody()
+		}
 
-	// Set headers
-	headers := map[string]string{
-		"Content-Type": "application/json",
-		"User-Agent":   "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:129.0) Gecko/20100101 Firefox/129.0",
-		// NB: Setting this particular Accept-Encoding because Claude will 403 when
-		// under heavy load (funny http code choice...) when the client doesn't
-		// explicitly state it accepts compressed payloads. Golang's HTTP client
-		// default behavior, setting "Accept-Encoding: gzip" also doesn't work
-		// (yet another funny Anthropic API quirk...), but this particular header
-		// value seems to always do the trick. Finding this value was a happy
-		// coincidence to discover — it's what the ruby http client does by default
-		// (sandworm was originally written in ruby).
-		"Accept-Encoding": "gzip;q=1.0, identity;q=0.3",
-		"Cookie":          fmt.Sprintf("sessionKey=%s", c.config.Get(sessionKey)),
-	}
-	for k, v := range headers {
-		req.Header.Set(k, v)
+		req, err := http.NewRequestWithContext(ctx, method, baseURL+"/api"+path, bodyReader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		if bodyReader != nil && contentLength >= 0 {
+			req.ContentLength = contentLength
+		}
+
+		// Set headers
+		headers := map[string]string{
+			"Content-Type": "application/json",
+			"User-Agent":   "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:129.0) Gecko/20100101 Firefox/129.0",
+			// NB: Setting this particular Accept-Encoding because Claude will 403 when
+			// under heavy load (funny http code choice...) when the client doesn't
+			// explicitly state it accepts compressed payloads. Golang's HTTP client
+			// default behavior, setting "Accept-Encoding: gzip" also doesn't work
+			// (yet another funny Anthropic API quirk...), but this particular header
+			// value seems to always do the trick. Finding this value was a happy
+			// coincidence to discover — it's what the ruby http client does by default
+			// (sandworm was originally written in ruby).
+			"Accept-Encoding": "gzip;q=1.0, identity;q=0.3",
+			"Cookie":          fmt.Sprintf("sessionKey=%s", c.config.Get(sessionKey)),
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		return req, nil
 	}
 
 	// Retry logic for transient errors
-	maxRetries := 3
+	policy := c.RetryPolicy.resolved()
+	maxAttempts := *policy.MaxRetries + 1
+
 	var respBody []byte
 	var lastErr error
+	var lastStatus int
+	var retryAfter time.Duration
 
-	for attempt := 0; attempt < maxRetries; attempt++ {
+	for attempt := 0; attempt < maxAttempts; attempt++ {
 		if attempt > 0 {
-			// Exponential backoff with jitter
-			backoff := time.Duration(1<<uint(attempt-1)) * time.Second
-			jitter := time.Duration(int64(float64(backoff) * 0.1 * (float64(2*time.Now().UnixNano()%100) / 100)))
-			time.Sleep(backoff + jitter)
+			// Exponential backoff, raised to the server's Retry-After if that
+			// is longer, capped at MaxBackoff, plus jitter. Cancellable via ctx.
+			backoff := *policy.InitialBackoff << uint(attempt-1)
+			if retryAfter > backoff {
+				backoff = retryAfter
+			}
+			if backoff > *policy.MaxBackoff {
+				backoff = *policy.MaxBackoff
+			}
+			jitter := time.Duration(int64(float64(backoff) * *policy.Jitter * (float64(2*time.Now().UnixNano()%100) / 100)))
+			select {
+			case <-ctx.Done():
+				return nil, fmt.Errorf("request canceled after %d attempts: %w", attempt, ctx.Err())
+			case <-time.After(backoff + jitter):
+			}
 		}
 
-		// Create a context with timeout
-		ctx, cancel := context.WithTimeout(req.Context(), 30*time.Second)
-		defer cancel()
+		// Clear the previous attempt's status/body/Retry-After now that
+		// they've been used for this attempt's backoff above -- otherwise a
+		// later attempt that fails before reading a status code (a dial
+		// error, a dropped connection, a body-read failure) would fall
+		// through to the stale values from an earlier attempt's response
+		// instead of the error that actually ended the loop.
+		lastStatus = 0
+		retryAfter = 0
+		respBody = nil
+
+		// Build and execute the request fresh each attempt, so a body
+		// consumed by a prior failed attempt is never replayed empty.
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
 
-		// Execute request with timeout context
-		resp, err := c.httpClient.Do(req.WithContext(ctx))
+		resp, err := c.httpClient.Do(req)
 		if err != nil {
-			lastErr = fmt.Errorf("request failed (attempt %d/%d): %w", attempt+1, maxRetries, err)
+			lastErr = fmt.Errorf("request failed (attempt %d/%d): %w", attempt+1, maxAttempts, err)
 			continue // Retry on network errors
 		}
 
@@ -342,10 +418,17 @@ func (c *Client) makeRequest(method, path string, body interface{}) ([]byte, err
 
 		// Check for error status codes that shouldn't be retried
 		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-			// Only retry on 5xx errors (server errors) or 429 (rate limit)
-			if resp.StatusCode >= 500 || resp.StatusCode == 429 {
+			lastStatus = resp.StatusCode
+			retryAfter = 0
+			if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+				if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+					retryAfter = d
+				}
+			}
+
+			if policy.RetryableStatus(resp.StatusCode) {
 				lastErr = fmt.Errorf("API request failed (attempt %d/%d): %d - %s",
-					attempt+1, maxRetries, resp.StatusCode, string(respBody))
+					attempt+1, maxAttempts, resp.StatusCode, string(respBody))
 				continue
 			}
 			return nil, fmt.Errorf("API request failed: %d - %s", resp.StatusCode, string(respBody))
@@ -368,13 +451,38 @@ func (c *Client) makeRequest(method, path string, body interface{}) ([]byte, err
 	}
 
 	// If we got here, all retries failed
-	return nil, fmt.Errorf("request failed after %d attempts: %w", maxRetries, lastErr)
+	if lastStatus == http.StatusTooManyRequests || lastStatus == http.StatusServiceUnavailable {
+		return nil, &RateLimitError{StatusCode: lastStatus, RetryAfter: retryAfter, Body: string(respBody)}
+	}
+	return nil, fmt.Errorf("request failed after %d attempts: %w", maxAttempts, lastErr)
+}
+
+// parseRetryAfter parses a Retry-After header value, which per RFC 9110 is
+// either a number of delay-seconds or an HTTP-date. It returns false if
+// value is empty or doesn't match either form.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
 }
 
 // MARK: Anthropic API requests
 
-func (c *Client) listOrganizations() ([]organization, error) {
-	data, err := c.makeRequest(http.MethodGet, "/organizations", nil)
+func (c *Client) listOrganizations(ctx context.Context) ([]organization, error) {
+	data, err := c.makeRequest(ctx, http.MethodGet, "/organizations", nil, -1)
 	if err != nil {
 		return nil, fmt.Errorf("listOrganizations: %w", err)
 	}
@@ -386,11 +494,13 @@ func (c *Client) listOrganizations() ([]organization, error) {
 	return orgs, nil
 }
 
-func (c *Client) listProjects() ([]project, error) {
+func (c *Client) listProjects(ctx context.Context) ([]project, error) {
 	data, err := c.makeRequest(
+		ctx,
 		http.MethodGet,
 		fmt.Sprintf("/organizations/%s/projects", c.config.Get(organizationID)),
 		nil,
+		-1,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("listProjects: %w", err)
@@ -403,8 +513,9 @@ func (c *Client) listProjects() ([]project, error) {
 	return projects, nil
 }
 
-func (c *Client) listDocuments() ([]document, error) {
+func (c *Client) listDocuments(ctx context.Context) ([]document, error) {
 	data, err := c.makeRequest(
+		ctx,
 		http.MethodGet,
 		fmt.Sprintf(
 			"/organizations/%s/projects/%s/docs",
@@ -412,6 +523,7 @@ func (c *Client) listDocuments() ([]document, error) {
 			c.config.Get(projectID),
 		),
 		nil,
+		-1,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("listDocuments: %w", err)
@@ -424,8 +536,9 @@ func (c *Client) listDocuments() ([]document, error) {
 	return docs, nil
 }
 
-func (c *Client) deleteDocument(id string) error {
+func (c *Client) deleteDocument(ctx context.Context, id string) error {
 	_, err := c.makeRequest(
+		ctx,
 		http.MethodDelete,
 		fmt.Sprintf(
 			"/organizations/%s/projects/%s/docs/%s",
@@ -434,6 +547,7 @@ func (c *Client) deleteDocument(id string) error {
 			id,
 		),
 		nil,
+		-1,
 	)
 	if err != nil {
 		return fmt.Errorf("deleteDocument: %w", err)
@@ -441,20 +555,23 @@ func (c *Client) deleteDocument(id string) error {
 	return nil
 }
 
-func (c *Client) uploadDocument(fileName, content string) (*document, error) {
-	body := map[string]string{
-		"file_name": fileName,
-		"content":   content,
+func (c *Client) uploadDocument(ctx context.Context, fileName, content string) (*document, error) {
+	contentLength := uploadBodyLen(fileName, content)
+	newBody := func() io.Reader {
+		r, _ := newUploadBody(fileName, content, c.UploadProgressFn)
+		return r
 	}
 
 	data, err := c.makeRequest(
+		ctx,
 		http.MethodPost,
 		fmt.Sprintf(
 			"/organizations/%s/projects/%s/docs",
 			c.config.Get(organizationID),
 			c.config.Get(projectID),
 		),
-		body,
+		newBody,
+		contentLength,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("uploadDocument: %w", err)
@@ -467,6 +584,172 @@ func (c *Client) uploadDocument(fileName, content string) (*document, error) {
 	return &doc, nil
 }
 
+// newUploadBody streams a {"file_name":"...","content":"..."} document
+// upload envelope as JSON without ever holding the fully-encoded payload in
+// memory: it writes the envelope directly to an io.Pipe, escaping content
+// as it goes, and returns the pipe's read side alongside the envelope's
+// exact byte length. If progressFn is non-nil, it's called after each write
+// with the number of bytes sent so far.
+func newUploadBody(fileName, content string, progressFn func(bytesSent, bytesTotal int64)) (io.Reader, int64) {
+	total := uploadBodyLen(fileName, content)
+
+	pr, pw := io.Pipe()
+	go func() {
+		var sent int64
+		write := func(p []byte) error {
+			n, err := pw.Write(p)
+			sent += int64(n)
+			if progressFn != nil {
+				progressFn(sent, total)
+			}
+			return err
+		}
+
+		err := func() error {
+			if err := write([]byte(`{"file_name":"`)); err != nil {
+				return err
+			}
+			if err := writeJSONEscaped(write, fileName); err != nil {
+				return err
+			}
+			if err := write([]byte(`","content":"`)); err != nil {
+				return err
+			}
+			if err := writeJSONEscaped(write, content); err != nil {
+				return err
+			}
+			return write([]byte(`"}`))
+		}()
+		pw.CloseWithError(err)
+	}()
+
+	return pr, total
+}
+
+// uploadBodyLen computes the exact encoded size of newUploadBody's output
+// without building it, so callers can set Content-Length up front.
+func uploadBodyLen(fileName, content string) int64 {
+	const prefix = `{"file_name":"`
+	const middle = `","content":"`
+	const suffix = `"}`
+	return int64(len(prefix) + jsonEscapedLen(fileName) + len(middle) + jsonEscapedLen(content) + len(suffix))
+}
+
+// invalidUTF8Escape is what encoding/json writes in place of a byte that
+// can't be part of a valid UTF-8 sequence, rather than passing it through.
+var invalidUTF8Escape = []byte("\\ufffd")
+
+// writeJSONEscaped streams s through write as a JSON string's contents
+// (i.e. without the surrounding quotes): it escapes the quote, backslash,
+// and control-character bytes required for valid JSON, and substitutes
+// invalidUTF8Escape for any byte that isn't valid UTF-8, so arbitrary file
+// content can't produce malformed JSON. Unlike encoding/json's default HTML
+// escaping, it doesn't escape '<', '>', '&', U+2028, or U+2029 -- this
+// output is an API request body, not HTML/JS, so those are passed through
+// unchanged. It still round-trips to the same string either way, in as few
+// write calls as possible: literal runs are written verbatim and only bytes
+// needing escaping are written individually.
+func writeJSONEscaped(write func([]byte) error, s string) error {
+	start := 0
+	for i := 0; i < len(s); {
+		b := s[i]
+		if b < utf8.RuneSelf {
+			esc, ok := jsonEscapes[b]
+			if !ok {
+				i++
+				continue
+			}
+			if start < i {
+				if err := write([]byte(s[start:i])); err != nil {
+					return err
+				}
+			}
+			if err := write(esc); err != nil {
+				return err
+			}
+			i++
+			start = i
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			if start < i {
+				if err := write([]byte(s[start:i])); err != nil {
+					return err
+				}
+			}
+			if err := write(invalidUTF8Escape); err != nil {
+				return err
+			}
+			i++
+			start = i
+			continue
+		}
+		i += size
+	}
+	if start < len(s) {
+		return write([]byte(s[start:]))
+	}
+	return nil
+}
+
+// jsonEscapedLen returns the byte length of s once escaped the same way
+// writeJSONEscaped encodes it.
+func jsonEscapedLen(s string) int {
+	n := 0
+	for i := 0; i < len(s); {
+		b := s[i]
+		if b < utf8.RuneSelf {
+			if esc, ok := jsonEscapes[b]; ok {
+				n += len(esc)
+			} else {
+				n++
+			}
+			i++
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			n += len(invalidUTF8Escape)
+			i++
+			continue
+		}
+		n += size
+		i += size
+	}
+	return n
+}
+
+// jsonEscapes maps each byte that must be escaped inside a JSON string to
+// its escaped form: the quote and backslash delimiters, plus control
+// characters. Everything else, including multi-byte UTF-8 sequences,
+// passes through unchanged.
+var jsonEscapes = buildJSONEscapes()
+
+func buildJSONEscapes() map[byte][]byte {
+	m := map[byte][]byte{
+		'"':  []byte(`\"`),
+		'\\': []byte(`\\`),
+	}
+	shortcuts := map[byte][]byte{
+		'\b': []byte(`\b`),
+		'\f': []byte(`\f`),
+		'\n': []byte(`\n`),
+		'\r': []byte(`\r`),
+		'\t': []byte(`\t`),
+	}
+	for b := byte(0); b < 0x20; b++ {
+		if esc, ok := shortcuts[b]; ok {
+			m[b] = esc
+			continue
+		}
+		m[b] = []byte(fmt.Sprintf(`\u%04x`, b))
+	}
+	return m
+}
+
 // MARK: Anthropic API types
 
 type organization struct {
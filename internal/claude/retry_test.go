@@ -0,0 +1,137 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/holonoms/sandworm/internal/config"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	future := time.Now().Add(2 * time.Minute).UTC().Format(http.TimeFormat)
+	past := time.Now().Add(-2 * time.Minute).UTC().Format(http.TimeFormat)
+
+	tests := []struct {
+		name    string
+		value   string
+		wantOK  bool
+		wantMin time.Duration // only checked when wantOK
+	}{
+		{name: "empty", value: "", wantOK: false},
+		{name: "delay seconds", value: "30", wantOK: true, wantMin: 30 * time.Second},
+		{name: "negative delay seconds", value: "-5", wantOK: false},
+		{name: "http-date in the future", value: future, wantOK: true, wantMin: time.Minute},
+		{name: "http-date in the past", value: past, wantOK: true, wantMin: 0},
+		{name: "garbage", value: "not-a-value", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, ok := parseRetryAfter(tt.value)
+			if ok != tt.wantOK {
+				t.Fatalf("parseRetryAfter(%q) ok = %v, want %v", tt.value, ok, tt.wantOK)
+			}
+			if ok && d < tt.wantMin {
+				t.Fatalf("parseRetryAfter(%q) = %v, want >= %v", tt.value, d, tt.wantMin)
+			}
+		})
+	}
+}
+
+// newTestClient returns a Client configured to talk to server, with a retry
+// policy fast enough for tests (tiny backoff, no jitter).
+func newTestClient(t *testing.T, server *httptest.Server) *Client {
+	t.Helper()
+
+	original := baseURL
+	baseURL = server.URL
+	t.Cleanup(func() { baseURL = original })
+
+	maxRetries := 1
+	initialBackoff := time.Millisecond
+	maxBackoff := 2 * time.Millisecond
+	jitter := 0.0
+
+	conf := config.New()
+	conf.Set(sessionKey, "test-session-key")
+	conf.Set(organizationID, "test-org")
+	conf.Set(projectID, "test-project")
+
+	c := New(conf)
+	c.RetryPolicy = RetryPolicy{
+		MaxRetries:     &maxRetries,
+		InitialBackoff: &initialBackoff,
+		MaxBackoff:     &maxBackoff,
+		Jitter:         &jitter,
+	}
+	return c
+}
+
+func TestMakeRequestRetryExhaustionReturnsRateLimitError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte("rate limited"))
+	}))
+	defer server.Close()
+
+	c := newTestClient(t, server)
+	_, err := c.listOrganizations(context.Background())
+
+	var rateLimitErr *RateLimitError
+	if !errors.As(err, &rateLimitErr) {
+		t.Fatalf("listOrganizations() error = %v, want a *RateLimitError", err)
+	}
+	if rateLimitErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("RateLimitError.StatusCode = %d, want %d", rateLimitErr.StatusCode, http.StatusTooManyRequests)
+	}
+}
+
+// TestMakeRequestRetryExhaustionDoesNotLeakStaleRateLimitError reproduces a
+// 429 on the first attempt followed by a transport-level failure (the
+// connection is hijacked and closed) on the final attempt: the returned
+// error must reflect the final attempt's actual failure, not a stale
+// *RateLimitError carried over from the first attempt's response.
+func TestMakeRequestRetryExhaustionDoesNotLeakStaleRateLimitError(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		if n == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte("rate limited"))
+			return
+		}
+
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			t.Fatalf("ResponseWriter does not support hijacking")
+		}
+		conn, _, err := hj.Hijack()
+		if err != nil {
+			t.Fatalf("hijack failed: %v", err)
+		}
+		conn.Close()
+	}))
+	defer server.Close()
+
+	c := newTestClient(t, server)
+	_, err := c.listOrganizations(context.Background())
+
+	if err == nil {
+		t.Fatal("listOrganizations() error = nil, want an error")
+	}
+	var rateLimitErr *RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		t.Fatalf("listOrganizations() error = %v, want the final transport failure, not a stale RateLimitError", err)
+	}
+	if !strings.Contains(err.Error(), "request failed after") {
+		t.Fatalf("listOrganizations() error = %v, want it to wrap the final attempt's failure", err)
+	}
+}
@@ -0,0 +1,72 @@
+package claude
+
+import (
+	"io"
+	"testing"
+)
+
+func TestUploadBodyLenMatchesActualEncodedLength(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		content  string
+	}{
+		{name: "empty", fileName: "", content: ""},
+		{name: "plain ascii", fileName: "readme.md", content: "hello world"},
+		{name: "needs escaping", fileName: `quote".txt`, content: "line1\nline2\ttabbed\\backslash"},
+		{name: "control characters", fileName: "file.txt", content: "\x00\x01\x1f"},
+		{name: "multi-byte utf-8", fileName: "日本語.txt", content: "héllo wörld 🎉"},
+		{name: "invalid utf-8", fileName: "bad.bin", content: "valid-\xff-invalid-\xfe-bytes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, total := newUploadBody(tt.fileName, tt.content, nil)
+			body, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("reading upload body: %v", err)
+			}
+			if int64(len(body)) != total {
+				t.Fatalf("newUploadBody returned total=%d, but actual body is %d bytes: %q", total, len(body), body)
+			}
+			if want := uploadBodyLen(tt.fileName, tt.content); want != total {
+				t.Fatalf("uploadBodyLen() = %d, want %d (newUploadBody's own total)", want, total)
+			}
+		})
+	}
+}
+
+func TestWriteJSONEscapedReplacesInvalidUTF8(t *testing.T) {
+	var out []byte
+	write := func(p []byte) error {
+		out = append(out, p...)
+		return nil
+	}
+
+	if err := writeJSONEscaped(write, "valid-\xff-invalid"); err != nil {
+		t.Fatalf("writeJSONEscaped: %v", err)
+	}
+
+	want := "valid-" + string(invalidUTF8Escape) + "-invalid"
+	if string(out) != want {
+		t.Fatalf("writeJSONEscaped output = %q, want %q", out, want)
+	}
+}
+
+func TestWriteJSONEscapedQuotesAndControlCharacters(t *testing.T) {
+	var out []byte
+	write := func(p []byte) error {
+		out = append(out, p...)
+		return nil
+	}
+
+	input := "a\"b\\c\nd\te"
+	if err := writeJSONEscaped(write, input); err != nil {
+		t.Fatalf("writeJSONEscaped: %v", err)
+	}
+
+	want := `a\"b\\c\nd\te`
+	if string(out) != want {
+		t.Fatalf("writeJSONEscaped output = %q, want %q", out, want)
+	}
+}
@@ -0,0 +1,252 @@
+// Package syncer reconciles a set of local files against a backend.Backend's
+// documents via a content-hash manifest, independent of where those
+// documents actually live (Claude Projects, a local directory, ...).
+package syncer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/holonoms/sandworm/internal/backend"
+	"github.com/holonoms/sandworm/internal/config"
+)
+
+// Configuration keys
+//
+// manifestKey keeps its original "claude.manifest" name (from before the
+// manifest logic moved out of package claude and into this backend-agnostic
+// syncer) so upgrading doesn't orphan an existing manifest, which would
+// otherwise cause every file to look unsynced and get re-uploaded as a
+// duplicate document.
+const manifestKey = "claude.manifest" // JSON-encoded map[fileName]manifestEntry
+
+// manifestEntry tracks the remote document backing a single synced file.
+type manifestEntry struct {
+	DocumentID  string `json:"document_id"`
+	ContentHash string `json:"content_hash"`
+}
+
+// Syncer reconciles local files against a backend.Backend's documents.
+type Syncer struct {
+	Backend backend.Backend
+	config  *config.Config
+}
+
+// New creates a Syncer that reconciles files against b, persisting its sync
+// manifest in conf.
+func New(b backend.Backend, conf *config.Config) *Syncer {
+	return &Syncer{Backend: b, config: conf}
+}
+
+// Setup initializes the backend's configuration, prompting for required
+// values if they're not already set. It's a context.Background() convenience
+// wrapper over s.Backend.Setup, mirroring Push and PurgeProjectFiles, since
+// backend.Backend's Setup must take a context to satisfy the interface and
+// so can't offer a non-context variant itself.
+func (s *Syncer) Setup(force bool) (bool, error) {
+	return s.Backend.Setup(context.Background(), force)
+}
+
+// Push uploads a file to the backend under fileName. If a document with
+// that name already exists there, it's replaced, but only if the content
+// has changed.
+func (s *Syncer) Push(filePath, fileName string) error {
+	return s.PushContext(context.Background(), filePath, fileName)
+}
+
+// PushContext is Push with a caller-supplied context.
+func (s *Syncer) PushContext(ctx context.Context, filePath, fileName string) error {
+	return s.SyncManifestContext(ctx, map[string]string{fileName: filePath}, nil)
+}
+
+// SyncManifest reconciles a set of local files against the backend's
+// documents. files maps each remote file name to the local path that should
+// back it. Files whose content hash is unchanged since the last sync are
+// left alone; new or changed files are (re-)uploaded; and documents tracked
+// by a previous sync but no longer present in files are deleted. progressFn,
+// if non-nil, is called once per upload or delete with the file name and the
+// overall step count, mirroring PurgeProjectFiles' progress callback.
+func (s *Syncer) SyncManifest(files map[string]string, progressFn func(fileName string, current, total int)) error {
+	return s.SyncManifestContext(context.Background(), files, progressFn)
+}
+
+// SyncManifestContext is SyncManifest with a caller-supplied context.
+//
+// progressFn reports per-file progress; backends that can report
+// finer-grained progress for an individual upload (e.g. *claude.Client's
+// UploadProgressFn) expose that separately, since it's specific to how that
+// backend transfers data.
+func (s *Syncer) SyncManifestContext(ctx context.Context, files map[string]string, progressFn func(fileName string, current, total int)) error {
+	manifest, err := s.loadManifest()
+	if err != nil {
+		return err
+	}
+
+	// Read and hash every file up front, both to detect changes and so the
+	// total step count below is known before any work starts.
+	type pendingFile struct {
+		content []byte
+		hash    string
+	}
+	pending := make(map[string]pendingFile, len(files))
+	for fileName, path := range files {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read file %q: %w", fileName, err)
+		}
+		pending[fileName] = pendingFile{content: content, hash: calculateContentHash(content)}
+	}
+
+	// The manifest may be missing an entry the backend actually has a
+	// document for (a stale or reset manifest, a file renamed back to an old
+	// name, ...). Backfill those from the backend's own listing, by file
+	// name, so such files get replaced below instead of uploaded as
+	// duplicates alongside the document the manifest lost track of.
+	var existingByName map[string]string
+	for fileName := range pending {
+		if _, ok := manifest[fileName]; ok {
+			continue
+		}
+		if existingByName == nil {
+			docs, err := s.Backend.ListDocuments(ctx)
+			if err != nil {
+				return err
+			}
+			existingByName = make(map[string]string, len(docs))
+			for _, doc := range docs {
+				existingByName[doc.FileName] = doc.ID
+			}
+		}
+		if id, ok := existingByName[fileName]; ok {
+			// Content hash is unknown for a backfilled entry, so it won't
+			// match pending's hash below: the existing document is deleted
+			// and the file re-uploaded, rather than uploaded alongside it.
+			manifest[fileName] = manifestEntry{DocumentID: id}
+		}
+	}
+
+	var toDelete, toUpload []string
+	for fileName := range manifest {
+		if _, ok := files[fileName]; !ok {
+			toDelete = append(toDelete, fileName)
+		}
+	}
+	for fileName, file := range pending {
+		entry, ok := manifest[fileName]
+		if !ok {
+			toUpload = append(toUpload, fileName)
+			continue
+		}
+		if entry.ContentHash != file.hash {
+			toDelete = append(toDelete, fileName)
+			toUpload = append(toUpload, fileName)
+		}
+	}
+	sort.Strings(toDelete)
+	sort.Strings(toUpload)
+
+	total := len(toDelete) + len(toUpload)
+	step := 0
+
+	for _, fileName := range toDelete {
+		step++
+		if progressFn != nil {
+			progressFn(fileName, step, total)
+		}
+
+		if err := s.Backend.DeleteDocument(ctx, manifest[fileName].DocumentID); err != nil {
+			if !errors.Is(err, backend.ErrNotFound) {
+				return err
+			}
+		}
+		delete(manifest, fileName)
+		if err := s.saveManifest(manifest); err != nil {
+			return err
+		}
+	}
+
+	for _, fileName := range toUpload {
+		step++
+		if progressFn != nil {
+			progressFn(fileName, step, total)
+		}
+
+		file := pending[fileName]
+		doc, err := s.Backend.UploadDocument(ctx, fileName, string(file.content))
+		if err != nil {
+			return err
+		}
+		manifest[fileName] = manifestEntry{DocumentID: doc.ID, ContentHash: file.hash}
+		if err := s.saveManifest(manifest); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PurgeProjectFiles removes every document from the backend.
+func (s *Syncer) PurgeProjectFiles(progressFn func(fileName string, current, total int)) (int, error) {
+	return s.PurgeProjectFilesContext(context.Background(), progressFn)
+}
+
+// PurgeProjectFilesContext is PurgeProjectFiles with a caller-supplied context.
+func (s *Syncer) PurgeProjectFilesContext(ctx context.Context, progressFn func(fileName string, current, total int)) (int, error) {
+	docs, err := s.Backend.ListDocuments(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, doc := range docs {
+		if progressFn != nil {
+			progressFn(doc.FileName, i+1, len(docs))
+		}
+
+		if err := s.Backend.DeleteDocument(ctx, doc.ID); err != nil {
+			if !errors.Is(err, backend.ErrNotFound) {
+				return i, err
+			}
+		}
+	}
+
+	// Clear the sync manifest now that every tracked document is gone.
+	if err := s.config.Delete(manifestKey); err != nil {
+		return len(docs), err
+	}
+
+	return len(docs), nil
+}
+
+// calculateContentHash computes a SHA-256 hash of the content
+func calculateContentHash(content []byte) string {
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])
+}
+
+// loadManifest reads and decodes the per-file sync manifest from config. It
+// returns an empty map if no manifest has been stored yet.
+func (s *Syncer) loadManifest() (map[string]manifestEntry, error) {
+	manifest := make(map[string]manifestEntry)
+	if !s.config.Has(manifestKey) {
+		return manifest, nil
+	}
+	if err := json.Unmarshal([]byte(s.config.Get(manifestKey)), &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// saveManifest encodes and stores the per-file sync manifest in config.
+func (s *Syncer) saveManifest(manifest map[string]manifestEntry) error {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	return s.config.Set(manifestKey, string(data))
+}